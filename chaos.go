@@ -0,0 +1,143 @@
+//go:build chaos
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// chaosEnabled gates chaos mode at runtime even in a chaos-tagged build, so
+// a binary built with -tags chaos is still inert by default.
+var chaosEnabled = os.Getenv("CLAUDE_TRACKER_CHAOS") == "1"
+
+// chaosConfig is the live configuration set via POST /debug/chaos.
+type chaosConfig struct {
+	FailRate           float64 `json:"fail_rate"`
+	LatencyMs          int     `json:"latency_ms"`
+	ErrorCode          int     `json:"error_code"`
+	StatFailCount      int32   `json:"stat_fail_count"`
+	OpenFailCount      int32   `json:"open_fail_count"`
+	FakeCacheDropCount int32   `json:"fake_cache_drop_count"`
+}
+
+var (
+	chaosMu sync.RWMutex
+	chaos   chaosConfig
+
+	statFailRemaining      int32
+	openFailRemaining      int32
+	fakeCacheDropRemaining int32
+)
+
+// chaosWrapMux wraps the daemon's handler with fault injection driven by
+// the last config posted to /debug/chaos: artificial latency and a
+// configurable rate of 5xx responses.
+func chaosWrapMux(h http.Handler) http.Handler {
+	if !chaosEnabled {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chaosMu.RLock()
+		cfg := chaos
+		chaosMu.RUnlock()
+
+		if cfg.LatencyMs > 0 {
+			time.Sleep(time.Duration(cfg.LatencyMs) * time.Millisecond)
+		}
+
+		if cfg.FailRate > 0 && rand.Float64() < cfg.FailRate {
+			code := cfg.ErrorCode
+			if code == 0 {
+				code = http.StatusServiceUnavailable
+			}
+			http.Error(w, "chaos: injected failure", code)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// registerChaosHandler registers the chaos control endpoint. It is a no-op
+// unless CLAUDE_TRACKER_CHAOS=1 is set.
+func registerChaosHandler(mux *http.ServeMux) {
+	if !chaosEnabled {
+		return
+	}
+	mux.HandleFunc("/debug/chaos", chaosDebugHandler)
+	logger.Printf("Chaos mode enabled (CLAUDE_TRACKER_CHAOS=1)")
+}
+
+func chaosDebugHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var cfg chaosConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid chaos config: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	chaosMu.Lock()
+	chaos = cfg
+	chaosMu.Unlock()
+
+	atomic.StoreInt32(&statFailRemaining, cfg.StatFailCount)
+	atomic.StoreInt32(&openFailRemaining, cfg.OpenFailCount)
+	atomic.StoreInt32(&fakeCacheDropRemaining, cfg.FakeCacheDropCount)
+
+	logger.Printf("Chaos config updated: fail_rate=%.2f latency_ms=%d error_code=%d stat_fail_count=%d open_fail_count=%d fake_cache_drop_count=%d",
+		cfg.FailRate, cfg.LatencyMs, cfg.ErrorCode, cfg.StatFailCount, cfg.OpenFailCount, cfg.FakeCacheDropCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// chaosStatFailure returns an injected error for the next N calls configured
+// via stat_fail_count, or nil otherwise.
+func chaosStatFailure() error {
+	if !chaosEnabled || !consumeOne(&statFailRemaining) {
+		return nil
+	}
+	return fmt.Errorf("chaos: injected os.Stat failure")
+}
+
+// chaosOpenFailure returns an injected error for the next N calls configured
+// via open_fail_count, or nil otherwise.
+func chaosOpenFailure() error {
+	if !chaosEnabled || !consumeOne(&openFailRemaining) {
+		return nil
+	}
+	return fmt.Errorf("chaos: injected os.Open failure")
+}
+
+// chaosFakeCacheDrop reports whether the next parsed cache_read value
+// should be forced to zero, to exercise the cache-invalidation detector
+// without waiting for a real checkpoint expiry.
+func chaosFakeCacheDrop() bool {
+	return chaosEnabled && consumeOne(&fakeCacheDropRemaining)
+}
+
+// consumeOne atomically decrements n if it is positive, reporting whether
+// it did so.
+func consumeOne(n *int32) bool {
+	for {
+		v := atomic.LoadInt32(n)
+		if v <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(n, v, v-1) {
+			return true
+		}
+	}
+}
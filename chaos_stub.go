@@ -0,0 +1,19 @@
+//go:build !chaos
+
+package main
+
+import "net/http"
+
+// The functions below are no-ops in ordinary builds. Build with -tags chaos
+// (and set CLAUDE_TRACKER_CHAOS=1 at runtime) to enable fault injection for
+// integration testing; see chaos.go.
+
+func chaosWrapMux(h http.Handler) http.Handler { return h }
+
+func registerChaosHandler(mux *http.ServeMux) {}
+
+func chaosStatFailure() error { return nil }
+
+func chaosOpenFailure() error { return nil }
+
+func chaosFakeCacheDrop() bool { return false }
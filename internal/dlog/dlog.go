@@ -0,0 +1,73 @@
+// Package dlog provides categorized debug tracing toggled by the CTTRACE
+// environment variable or the --trace flag, independent of the daemon's
+// --log-level. It borrows the STTRACE=net,idx,pull pattern: each category's
+// Printf returns without logging when the category isn't enabled.
+package dlog
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// Category is a single debug-tracing category.
+type Category struct {
+	name    string
+	enabled bool
+}
+
+var (
+	std = log.New(os.Stderr, "", log.LstdFlags)
+
+	// Parse traces per-line JSON extraction in parseFile.
+	Parse = &Category{name: "parse"}
+	// Watch traces fsnotify events observed by watchLoop.
+	Watch = &Category{name: "watch"}
+	// Cache traces cache-invalidation heuristic decisions.
+	Cache = &Category{name: "cache"}
+	// HTTP traces per-request timing.
+	HTTP = &Category{name: "http"}
+	// Cleanup traces idle/session eviction decisions.
+	Cleanup = &Category{name: "cleanup"}
+
+	all = []*Category{Parse, Watch, Cache, HTTP, Cleanup}
+)
+
+// Printf logs a formatted trace line for this category if it is enabled.
+func (c *Category) Printf(format string, args ...interface{}) {
+	if c == nil || !c.enabled {
+		return
+	}
+	std.Printf("["+c.name+"] "+format, args...)
+}
+
+// Enabled reports whether this category is currently active.
+func (c *Category) Enabled() bool {
+	return c != nil && c.enabled
+}
+
+// Init enables the categories named in spec, a comma-separated list such as
+// "parse,watch" or "all" to enable every category. An empty spec enables
+// nothing. Unknown category names are ignored.
+func Init(spec string) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return
+	}
+
+	if spec == "all" {
+		for _, c := range all {
+			c.enabled = true
+		}
+		return
+	}
+
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		for _, c := range all {
+			if c.name == name {
+				c.enabled = true
+			}
+		}
+	}
+}
@@ -2,19 +2,23 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/md2k/claude-token-tracker/internal/dlog"
 )
 
 // TokenUsage holds cumulative token counts for a session
@@ -27,21 +31,32 @@ type TokenUsage struct {
 
 // SessionTracker tracks a single transcript file
 type SessionTracker struct {
-	path                 string
-	lastSize             int64
-	lastModTime          time.Time
-	lastAccess           time.Time
-	startTime            time.Time
-	usage                TokenUsage
-	mu                   sync.RWMutex
-	watcher              *fsnotify.Watcher
-	stopChan             chan struct{}
-	stopped              bool
-	parseCount           int64
-	totalParseTime       time.Duration
-	cacheInvalidatedAt   time.Time
-	lastCacheReadTokens  int64
+	path                  string
+	lastSize              int64
+	lastModTime           time.Time
+	lastAccess            time.Time
+	startTime             time.Time
+	usage                 TokenUsage
+	mu                    sync.RWMutex
+	watcher               *fsnotify.Watcher
+	stopChan              chan struct{}
+	stopped               bool
+	parseCount            int64
+	totalParseTime        time.Duration
+	cacheInvalidatedAt    time.Time
+	lastCacheReadTokens   int64
 	lastCacheCreateTokens int64
+	store                 *StateStore
+	subscribers           map[chan Event]struct{}
+}
+
+// Event is a single update pushed to /events subscribers when parseFile
+// observes new usage lines or detects a cache invalidation.
+type Event struct {
+	Type            string     `json:"type"`
+	Usage           TokenUsage `json:"usage"`
+	CacheRebuilding bool       `json:"cache_rebuilding"`
+	Timestamp       time.Time  `json:"timestamp"`
 }
 
 // Config holds daemon configuration
@@ -53,6 +68,10 @@ type Config struct {
 	CacheDropThreshold        int64
 	LogLevel                  string
 	PIDFile                   string
+	StateFile                 string
+	NoPersist                 bool
+	ShutdownTimeout           time.Duration
+	Trace                     string
 	NeverTimeout              bool
 	NeverIdleStop             bool
 }
@@ -65,6 +84,9 @@ type Daemon struct {
 	cleanupCh    chan struct{}
 	lastRequest  time.Time
 	requestMu    sync.RWMutex
+	store        *StateStore
+	httpServer   *http.Server
+	shutdownOnce sync.Once
 }
 
 var (
@@ -82,6 +104,9 @@ func main() {
 		logger.SetOutput(os.NewFile(0, os.DevNull))
 	}
 
+	// Enable categorized debug tracing, if requested
+	dlog.Init(config.Trace)
+
 	// Write PID file
 	if err := writePIDFile(config.PIDFile); err != nil {
 		logger.Fatalf("Failed to write PID file: %v", err)
@@ -96,6 +121,16 @@ func main() {
 		lastRequest: time.Now(),
 	}
 
+	// Open the persistent state store unless disabled
+	if !config.NoPersist {
+		store, err := openStateStore(config.StateFile)
+		if err != nil {
+			logger.Fatalf("Failed to open state file %s: %v", config.StateFile, err)
+		}
+		daemon.store = store
+		defer store.Close()
+	}
+
 	// Start cleanup goroutine if timeout is enabled
 	if !config.NeverTimeout {
 		go daemon.cleanupLoop()
@@ -107,17 +142,47 @@ func main() {
 	}
 
 	// Setup HTTP server
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/tokens", tokensHandler)
-	http.HandleFunc("/status", statusHandler)
-	http.HandleFunc("/metrics", metricsHandler)
-	http.HandleFunc("/shutdown", shutdownHandler)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", traceHTTP("health", healthHandler))
+	mux.HandleFunc("/tokens", traceHTTP("tokens", tokensHandler))
+	mux.HandleFunc("/events", traceHTTP("events", eventsHandler))
+	mux.HandleFunc("/status", traceHTTP("status", statusHandler))
+	mux.HandleFunc("/metrics", traceHTTP("metrics", metricsHandler))
+	mux.HandleFunc("/shutdown", traceHTTP("shutdown", shutdownHandler))
+	mux.HandleFunc("/reset", traceHTTP("reset", resetHandler))
+	registerChaosHandler(mux)
 
 	addr := fmt.Sprintf(":%d", config.Port)
+	daemon.httpServer = &http.Server{Addr: addr, Handler: chaosWrapMux(mux)}
+
 	logger.Printf("Token tracker daemon starting on %s (timeout: %v)", addr, formatTimeout(config))
 
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		logger.Fatalf("Server failed: %v", err)
+	go func() {
+		if err := daemon.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	waitForSignal()
+}
+
+// waitForSignal blocks until SIGINT or SIGTERM initiates a graceful
+// shutdown, reloading configuration on SIGHUP instead of exiting.
+func waitForSignal() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for sig := range sigCh {
+		if sig == syscall.SIGHUP {
+			daemon.reloadLogLevel()
+			continue
+		}
+
+		logger.Printf("Received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), daemon.config.ShutdownTimeout)
+		daemon.Shutdown(ctx)
+		cancel()
+		return
 	}
 }
 
@@ -129,6 +194,10 @@ func parseFlags() Config {
 	cacheDropThreshold := flag.Int64("cache-drop-threshold", 10000, "Cache drop threshold in tokens to detect invalidation (default: 10000)")
 	logLevel := flag.String("log-level", "info", "Log level (info, silent)")
 	pidFile := flag.String("pid-file", "", "PID file path (default: ~/.claude/token-tracker.pid)")
+	stateFile := flag.String("state-file", "", "State file path for persisted token counts (default: ~/.claude/token-tracker.db)")
+	noPersist := flag.Bool("no-persist", false, "Disable persistent on-disk state")
+	shutdownTimeoutStr := flag.String("shutdown-timeout", "10s", "Max time to wait for in-flight requests to drain on shutdown (e.g., 10s, 30s)")
+	trace := flag.String("trace", "", "Comma-separated debug trace categories to enable (parse,watch,cache,http,cleanup or 'all'); also settable via CTTRACE")
 
 	flag.Parse()
 
@@ -166,6 +235,18 @@ func parseFlags() Config {
 		log.Fatalf("Invalid cache-rebuild-alert format: %v", err)
 	}
 
+	// Parse shutdown timeout
+	shutdownTimeout, err := time.ParseDuration(*shutdownTimeoutStr)
+	if err != nil {
+		log.Fatalf("Invalid shutdown-timeout format: %v", err)
+	}
+
+	// --trace takes precedence over CTTRACE when both are set
+	traceSpec := *trace
+	if traceSpec == "" {
+		traceSpec = os.Getenv("CTTRACE")
+	}
+
 	// Determine PID file path
 	pidPath := *pidFile
 	if pidPath == "" {
@@ -176,6 +257,16 @@ func parseFlags() Config {
 		pidPath = filepath.Join(home, ".claude", "token-tracker.pid")
 	}
 
+	// Determine state file path
+	statePath := *stateFile
+	if statePath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatalf("Cannot determine home directory: %v", err)
+		}
+		statePath = filepath.Join(home, ".claude", "token-tracker.db")
+	}
+
 	return Config{
 		Port:                      *port,
 		Timeout:                   timeout,
@@ -184,6 +275,10 @@ func parseFlags() Config {
 		CacheDropThreshold:        *cacheDropThreshold,
 		LogLevel:                  *logLevel,
 		PIDFile:                   pidPath,
+		StateFile:                 statePath,
+		NoPersist:                 *noPersist,
+		ShutdownTimeout:           shutdownTimeout,
+		Trace:                     traceSpec,
 		NeverTimeout:              neverTimeout,
 		NeverIdleStop:             neverIdleStop,
 	}
@@ -228,6 +323,16 @@ func processExists(pid int) bool {
 	return err == nil
 }
 
+// traceHTTP wraps a handler to record its timing under the "http" trace
+// category when tracing is enabled.
+func traceHTTP(name string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		h(w, r)
+		dlog.HTTP.Printf("%s %s (%s) took %s", r.Method, name, r.URL.RawQuery, time.Since(start))
+	}
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	daemon.updateLastRequest()
 	w.Header().Set("Content-Type", "application/json")
@@ -240,23 +345,51 @@ func shutdownHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.Printf("Shutdown requested via API")
 
-	// Stop all session trackers and exit gracefully
 	go func() {
-		daemon.mu.Lock()
-		for path, tracker := range daemon.sessions {
-			tracker.stop()
-			logger.Printf("Stopped tracking: %s", path)
-		}
-		daemon.mu.Unlock()
-
-		// Give time for response to be sent
+		// Give time for this response to flush before we start draining
 		time.Sleep(100 * time.Millisecond)
 
-		// Exit gracefully (defer will clean up PID file)
-		os.Exit(0)
+		ctx, cancel := context.WithTimeout(context.Background(), daemon.config.ShutdownTimeout)
+		defer cancel()
+		daemon.Shutdown(ctx)
 	}()
 }
 
+func resetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	daemon.updateLastRequest()
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "Missing 'path' parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Drop the in-memory tracker, if any, so a later request rebuilds it from scratch
+	daemon.mu.Lock()
+	if tracker, exists := daemon.sessions[path]; exists {
+		tracker.stop()
+		delete(daemon.sessions, path)
+	}
+	daemon.mu.Unlock()
+
+	if daemon.store != nil {
+		if err := daemon.store.delete(path); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to reset state: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	logger.Printf("Reset state for: %s", path)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+}
+
 func tokensHandler(w http.ResponseWriter, r *http.Request) {
 	daemon.updateLastRequest()
 
@@ -291,15 +424,67 @@ func tokensHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"input_tokens":            usage.InputTokens,
-		"output_tokens":           usage.OutputTokens,
-		"cache_read_tokens":       usage.CacheReadTokens,
-		"cache_create_tokens":     usage.CacheCreateTokens,
+		"input_tokens":             usage.InputTokens,
+		"output_tokens":            usage.OutputTokens,
+		"cache_read_tokens":        usage.CacheReadTokens,
+		"cache_create_tokens":      usage.CacheCreateTokens,
 		"last_cache_create_tokens": lastCacheCreate,
-		"cache_rebuilding":        cacheRebuilding,
+		"cache_rebuilding":         cacheRebuilding,
 	})
 }
 
+// eventsHandler streams a text/event-stream of Event updates for a
+// transcript, replacing the poll-/tokens-every-second pattern that shells
+// use for statusline updates.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	daemon.updateLastRequest()
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "Missing 'path' parameter", http.StatusBadRequest)
+		return
+	}
+
+	tracker, err := daemon.getOrCreateTracker(path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to track file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := tracker.subscribe()
+	defer tracker.unsubscribe(ch)
+
+	logger.Printf("Subscribed to events for: %s", path)
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			logger.Printf("Unsubscribed from events for: %s", path)
+			return
+		}
+	}
+}
+
 func statusHandler(w http.ResponseWriter, r *http.Request) {
 	daemon.updateLastRequest()
 
@@ -329,6 +514,11 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 func metricsHandler(w http.ResponseWriter, r *http.Request) {
 	daemon.updateLastRequest()
 
+	if wantsPrometheusFormat(r) {
+		writePrometheusMetrics(w)
+		return
+	}
+
 	daemon.mu.RLock()
 	sessionCount := len(daemon.sessions)
 	sessions := make([]map[string]interface{}, 0, sessionCount)
@@ -363,15 +553,121 @@ func metricsHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"active_sessions":     sessionCount,
-		"session_timeout":     formatTimeout(daemon.config),
-		"idle_timeout":        formatIdleTimeout(daemon.config),
-		"last_request":        lastReq.Format(time.RFC3339),
-		"idle_for":            time.Since(lastReq).Round(time.Second).String(),
-		"sessions":            sessions,
+		"active_sessions": sessionCount,
+		"session_timeout": formatTimeout(daemon.config),
+		"idle_timeout":    formatIdleTimeout(daemon.config),
+		"last_request":    lastReq.Format(time.RFC3339),
+		"idle_for":        time.Since(lastReq).Round(time.Second).String(),
+		"sessions":        sessions,
 	})
 }
 
+// wantsPrometheusFormat reports whether the client asked for the Prometheus
+// text exposition format instead of the default JSON, either via the
+// standard content-negotiation header or the `?format=prometheus` override.
+func wantsPrometheusFormat(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "prometheus" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/plain")
+}
+
+// promSession is a snapshot of the fields of a SessionTracker needed to
+// render Prometheus metrics, taken while holding its lock.
+type promSession struct {
+	path            string
+	usage           TokenUsage
+	cacheRebuilding bool
+	parseCount      int64
+	totalParseTime  time.Duration
+}
+
+// writePrometheusMetrics renders the daemon's state in the Prometheus text
+// exposition format (version 0.0.4), grouping all series for a metric family
+// together under a single HELP/TYPE block as the format requires.
+func writePrometheusMetrics(w http.ResponseWriter) {
+	daemon.mu.RLock()
+	sessionCount := len(daemon.sessions)
+	sessions := make([]promSession, 0, sessionCount)
+	for path, tracker := range daemon.sessions {
+		tracker.mu.RLock()
+		cacheRebuilding := !tracker.cacheInvalidatedAt.IsZero() &&
+			time.Since(tracker.cacheInvalidatedAt) < daemon.config.CacheRebuildAlertDuration
+		sessions = append(sessions, promSession{
+			path:            path,
+			usage:           tracker.usage,
+			cacheRebuilding: cacheRebuilding,
+			parseCount:      tracker.parseCount,
+			totalParseTime:  tracker.totalParseTime,
+		})
+		tracker.mu.RUnlock()
+	}
+	daemon.mu.RUnlock()
+
+	daemon.requestMu.RLock()
+	lastReq := daemon.lastRequest
+	daemon.requestMu.RUnlock()
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# HELP claude_tokens_input_total Cumulative input tokens consumed by a tracked transcript.\n")
+	fmt.Fprintf(&b, "# TYPE claude_tokens_input_total counter\n")
+	for _, s := range sessions {
+		fmt.Fprintf(&b, "claude_tokens_input_total{path=%q} %d\n", s.path, s.usage.InputTokens)
+	}
+
+	fmt.Fprintf(&b, "# HELP claude_tokens_output_total Cumulative output tokens generated for a tracked transcript.\n")
+	fmt.Fprintf(&b, "# TYPE claude_tokens_output_total counter\n")
+	for _, s := range sessions {
+		fmt.Fprintf(&b, "claude_tokens_output_total{path=%q} %d\n", s.path, s.usage.OutputTokens)
+	}
+
+	fmt.Fprintf(&b, "# HELP claude_tokens_cache_read_total Cumulative prompt-cache read tokens for a tracked transcript.\n")
+	fmt.Fprintf(&b, "# TYPE claude_tokens_cache_read_total counter\n")
+	for _, s := range sessions {
+		fmt.Fprintf(&b, "claude_tokens_cache_read_total{path=%q} %d\n", s.path, s.usage.CacheReadTokens)
+	}
+
+	fmt.Fprintf(&b, "# HELP claude_tokens_cache_create_total Cumulative prompt-cache creation tokens for a tracked transcript.\n")
+	fmt.Fprintf(&b, "# TYPE claude_tokens_cache_create_total counter\n")
+	for _, s := range sessions {
+		fmt.Fprintf(&b, "claude_tokens_cache_create_total{path=%q} %d\n", s.path, s.usage.CacheCreateTokens)
+	}
+
+	fmt.Fprintf(&b, "# HELP claude_cache_rebuilding Whether a tracked transcript is currently within the cache-rebuild alert window (1) or not (0).\n")
+	fmt.Fprintf(&b, "# TYPE claude_cache_rebuilding gauge\n")
+	for _, s := range sessions {
+		fmt.Fprintf(&b, "claude_cache_rebuilding{path=%q} %s\n", s.path, promBool(s.cacheRebuilding))
+	}
+
+	fmt.Fprintf(&b, "# HELP claude_parse_duration_seconds Time spent parsing new transcript lines per session.\n")
+	fmt.Fprintf(&b, "# TYPE claude_parse_duration_seconds histogram\n")
+	for _, s := range sessions {
+		fmt.Fprintf(&b, "claude_parse_duration_seconds_bucket{path=%q,le=\"+Inf\"} %d\n", s.path, s.parseCount)
+		fmt.Fprintf(&b, "claude_parse_duration_seconds_sum{path=%q} %f\n", s.path, s.totalParseTime.Seconds())
+		fmt.Fprintf(&b, "claude_parse_duration_seconds_count{path=%q} %d\n", s.path, s.parseCount)
+	}
+
+	fmt.Fprintf(&b, "# HELP claude_sessions_active Number of transcripts currently being tracked.\n")
+	fmt.Fprintf(&b, "# TYPE claude_sessions_active gauge\n")
+	fmt.Fprintf(&b, "claude_sessions_active %d\n", sessionCount)
+
+	fmt.Fprintf(&b, "# HELP claude_idle_seconds Time since the daemon last served a request.\n")
+	fmt.Fprintf(&b, "# TYPE claude_idle_seconds gauge\n")
+	fmt.Fprintf(&b, "claude_idle_seconds %f\n", time.Since(lastReq).Seconds())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// promBool renders a bool as a Prometheus 0/1 gauge value.
+func promBool(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
 func formatIdleTimeout(config Config) string {
 	if config.NeverIdleStop {
 		return "never"
@@ -398,7 +694,7 @@ func (d *Daemon) getOrCreateTracker(path string) (*SessionTracker, error) {
 		return tracker, nil
 	}
 
-	tracker, err := newSessionTracker(path)
+	tracker, err := newSessionTracker(path, d.store)
 	if err != nil {
 		return nil, err
 	}
@@ -409,7 +705,7 @@ func (d *Daemon) getOrCreateTracker(path string) (*SessionTracker, error) {
 	return tracker, nil
 }
 
-func newSessionTracker(path string) (*SessionTracker, error) {
+func newSessionTracker(path string, store *StateStore) (*SessionTracker, error) {
 	// Create file watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -423,6 +719,22 @@ func newSessionTracker(path string) (*SessionTracker, error) {
 		startTime:  now,
 		watcher:    watcher,
 		stopChan:   make(chan struct{}),
+		store:      store,
+	}
+
+	// Hydrate from persisted state, if any, so we resume incremental
+	// parsing instead of re-reading the transcript from byte 0
+	if store != nil {
+		if state, ok, err := store.load(path); err != nil {
+			logger.Printf("Failed to load persisted state for %s: %v", path, err)
+		} else if ok {
+			tracker.usage = state.Usage
+			tracker.lastSize = state.LastSize
+			tracker.lastModTime = state.LastModTime
+			tracker.cacheInvalidatedAt = state.CacheInvalidatedAt
+			tracker.lastCacheReadTokens = state.LastCacheReadTokens
+			tracker.lastCacheCreateTokens = state.LastCacheCreateTokens
+		}
 	}
 
 	// Initial parse
@@ -452,6 +764,11 @@ func (t *SessionTracker) parseFile() error {
 	}()
 
 	info, err := os.Stat(t.path)
+	if err == nil {
+		if chaosErr := chaosStatFailure(); chaosErr != nil {
+			err = chaosErr
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -462,6 +779,12 @@ func (t *SessionTracker) parseFile() error {
 	}
 
 	file, err := os.Open(t.path)
+	if err == nil {
+		if chaosErr := chaosOpenFailure(); chaosErr != nil {
+			file.Close()
+			err = chaosErr
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -484,6 +807,7 @@ func (t *SessionTracker) parseFile() error {
 
 		var data map[string]interface{}
 		if err := json.Unmarshal([]byte(line), &data); err != nil {
+			dlog.Parse.Printf("%s: skipping unparseable line: %v", t.path, err)
 			continue
 		}
 
@@ -496,6 +820,7 @@ func (t *SessionTracker) parseFile() error {
 			usage, _ = data["usage"].(map[string]interface{})
 		}
 		if usage == nil {
+			dlog.Parse.Printf("%s: line matched \"usage\" but no usage object found", t.path)
 			continue
 		}
 
@@ -508,6 +833,11 @@ func (t *SessionTracker) parseFile() error {
 			cacheCreate = int64(val)
 		}
 
+		if chaosFakeCacheDrop() {
+			dlog.Cache.Printf("%s: chaos forcing cache_read to 0 (was %d)", t.path, cacheRead)
+			cacheRead = 0
+		}
+
 		// Update totals first
 		t.mu.Lock()
 		if val, ok := usage["input_tokens"].(float64); ok {
@@ -524,12 +854,19 @@ func (t *SessionTracker) parseFile() error {
 		// Track the last individual cache create value (not cumulative)
 		t.lastCacheCreateTokens = cacheCreate
 
+		dlog.Parse.Printf("%s: input=%d output=%d cache_read=%d cache_create=%d",
+			t.path, t.usage.InputTokens, t.usage.OutputTokens, t.usage.CacheReadTokens, t.usage.CacheCreateTokens)
+
 		// Detect cache invalidation via large drop in cache_read
 		// This handles checkpoint-based cache expiration where segments expire gradually
+		invalidated := false
 		if t.lastCacheReadTokens > 0 && cacheRead < t.lastCacheReadTokens {
 			drop := t.lastCacheReadTokens - cacheRead
+			dlog.Cache.Printf("%s: cache_read dropped from %d to %d (delta %d, threshold %d)",
+				t.path, t.lastCacheReadTokens, cacheRead, drop, daemon.config.CacheDropThreshold)
 			if drop >= daemon.config.CacheDropThreshold {
 				t.cacheInvalidatedAt = time.Now()
+				invalidated = true
 				logger.Printf("Cache invalidation detected for %s: %d tokens dropped (was %d, now %d)",
 					t.path, drop, t.lastCacheReadTokens, cacheRead)
 			}
@@ -540,7 +877,19 @@ func (t *SessionTracker) parseFile() error {
 			t.lastCacheReadTokens = cacheRead
 		}
 
+		ev := Event{
+			Type:            "usage",
+			Usage:           t.usage,
+			CacheRebuilding: !t.cacheInvalidatedAt.IsZero() && time.Since(t.cacheInvalidatedAt) < daemon.config.CacheRebuildAlertDuration,
+			Timestamp:       time.Now(),
+		}
+		if invalidated {
+			ev.Type = "cache_invalidated"
+		}
+
 		t.mu.Unlock()
+
+		t.publish(ev)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -551,9 +900,31 @@ func (t *SessionTracker) parseFile() error {
 	t.lastSize = info.Size()
 	t.lastModTime = info.ModTime()
 
+	if t.store != nil {
+		if err := t.persist(); err != nil {
+			logger.Printf("Failed to persist state for %s: %v", t.path, err)
+		}
+	}
+
 	return nil
 }
 
+// persist snapshots the tracker's current state to its StateStore.
+func (t *SessionTracker) persist() error {
+	t.mu.RLock()
+	state := persistedSession{
+		Usage:                 t.usage,
+		LastSize:              t.lastSize,
+		LastModTime:           t.lastModTime,
+		CacheInvalidatedAt:    t.cacheInvalidatedAt,
+		LastCacheReadTokens:   t.lastCacheReadTokens,
+		LastCacheCreateTokens: t.lastCacheCreateTokens,
+	}
+	t.mu.RUnlock()
+
+	return t.store.save(t.path, state)
+}
+
 func (t *SessionTracker) watchLoop() {
 	for {
 		select {
@@ -561,6 +932,7 @@ func (t *SessionTracker) watchLoop() {
 			if !ok {
 				return
 			}
+			dlog.Watch.Printf("%s: event %s", t.path, event.Op)
 			if event.Op&fsnotify.Write == fsnotify.Write {
 				if err := t.parseFile(); err != nil {
 					logger.Printf("Error parsing %s: %v", t.path, err)
@@ -590,6 +962,52 @@ func (t *SessionTracker) stop() {
 	t.watcher.Close()
 }
 
+// subscribe registers a new event channel for this tracker. Callers must
+// unsubscribe when done to avoid leaking the channel.
+func (t *SessionTracker) subscribe() chan Event {
+	ch := make(chan Event, 16)
+
+	t.mu.Lock()
+	if t.subscribers == nil {
+		t.subscribers = make(map[chan Event]struct{})
+	}
+	t.subscribers[ch] = struct{}{}
+	t.mu.Unlock()
+
+	return ch
+}
+
+// unsubscribe removes and closes a channel previously returned by subscribe.
+func (t *SessionTracker) unsubscribe(ch chan Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// publish may have already dropped and closed this channel if the
+	// subscriber was too slow; don't close it twice.
+	if _, ok := t.subscribers[ch]; !ok {
+		return
+	}
+	delete(t.subscribers, ch)
+	close(ch)
+}
+
+// publish fans ev out to every subscriber. A subscriber whose buffer is
+// full is dropped rather than allowed to block parsing.
+func (t *SessionTracker) publish(ev Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for ch := range t.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			logger.Printf("Dropping slow /events subscriber for %s", t.path)
+			delete(t.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
 func (d *Daemon) cleanupLoop() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
@@ -615,7 +1033,10 @@ func (d *Daemon) cleanupInactive() {
 		lastAccess := tracker.lastAccess
 		tracker.mu.RUnlock()
 
-		if now.Sub(lastAccess) > d.config.Timeout {
+		idle := now.Sub(lastAccess)
+		dlog.Cleanup.Printf("%s: idle for %s (timeout %s)", path, idle, d.config.Timeout)
+
+		if idle > d.config.Timeout {
 			tracker.stop()
 			delete(d.sessions, path)
 			logger.Printf("Stopped tracking (inactive): %s", path)
@@ -643,19 +1064,57 @@ func (d *Daemon) idleShutdownLoop() {
 			if time.Since(lastReq) > d.config.IdleTimeout {
 				logger.Printf("No requests for %v, shutting down gracefully", d.config.IdleTimeout)
 
-				// Stop all trackers
-				d.mu.Lock()
-				for path, tracker := range d.sessions {
-					tracker.stop()
-					logger.Printf("Stopped tracking: %s", path)
-				}
-				d.mu.Unlock()
-
-				// Exit (defer will clean up PID file)
-				os.Exit(0)
+				ctx, cancel := context.WithTimeout(context.Background(), d.config.ShutdownTimeout)
+				defer cancel()
+				d.Shutdown(ctx)
 			}
 		case <-d.cleanupCh:
 			return
 		}
 	}
 }
+
+// Shutdown drains in-flight HTTP requests (bounded by ctx), stops every
+// session tracker, flushes the state store, removes the PID file, and
+// exits the process. If the drain doesn't complete before ctx is done,
+// in-flight connections are force-closed instead of waiting indefinitely.
+func (d *Daemon) Shutdown(ctx context.Context) {
+	d.shutdownOnce.Do(func() { d.doShutdown(ctx) })
+}
+
+// doShutdown performs the actual drain/close/exit sequence and must only
+// ever run once; see shutdownOnce in Shutdown.
+func (d *Daemon) doShutdown(ctx context.Context) {
+	if d.httpServer != nil {
+		if err := d.httpServer.Shutdown(ctx); err != nil {
+			logger.Printf("HTTP drain did not complete (%v), forcing close", err)
+			d.httpServer.Close()
+		}
+	}
+
+	d.mu.Lock()
+	for path, tracker := range d.sessions {
+		tracker.stop()
+		logger.Printf("Stopped tracking: %s", path)
+	}
+	d.mu.Unlock()
+
+	if d.store != nil {
+		d.store.Close()
+	}
+
+	os.Remove(d.config.PIDFile)
+
+	os.Exit(0)
+}
+
+// reloadLogLevel re-applies the configured log level to the logger. It is
+// invoked on SIGHUP instead of the process exiting.
+func (d *Daemon) reloadLogLevel() {
+	if d.config.LogLevel == "silent" {
+		logger.SetOutput(os.NewFile(0, os.DevNull))
+	} else {
+		logger.SetOutput(os.Stdout)
+	}
+	logger.Printf("Reloaded configuration (log-level=%s)", d.config.LogLevel)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// sessionsBucket holds one entry per tracked transcript path.
+const sessionsBucket = "sessions"
+
+// persistedSession is the on-disk snapshot of a SessionTracker, enough to
+// resume incremental parsing without re-reading the transcript from byte 0.
+type persistedSession struct {
+	Usage                 TokenUsage `json:"usage"`
+	LastSize              int64      `json:"last_size"`
+	LastModTime           time.Time  `json:"last_mod_time"`
+	CacheInvalidatedAt    time.Time  `json:"cache_invalidated_at"`
+	LastCacheReadTokens   int64      `json:"last_cache_read_tokens"`
+	LastCacheCreateTokens int64      `json:"last_cache_create_tokens"`
+}
+
+// StateStore persists SessionTracker state to an embedded bbolt database so
+// cumulative token counts survive daemon restarts.
+type StateStore struct {
+	db *bolt.DB
+}
+
+// openStateStore opens (creating if necessary) the bbolt database at path.
+func openStateStore(path string) (*StateStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(sessionsBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &StateStore{db: db}, nil
+}
+
+// save writes the persisted state for path, overwriting any previous entry.
+func (s *StateStore) save(path string, state persistedSession) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(sessionsBucket)).Put([]byte(path), data)
+	})
+}
+
+// load returns the persisted state for path, or ok=false if none exists.
+func (s *StateStore) load(path string) (state persistedSession, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(sessionsBucket)).Get([]byte(path))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &state)
+	})
+	return state, ok, err
+}
+
+// delete removes the persisted state for path, if any.
+func (s *StateStore) delete(path string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(sessionsBucket)).Delete([]byte(path))
+	})
+}
+
+// Close releases the underlying bbolt database.
+func (s *StateStore) Close() error {
+	return s.db.Close()
+}